@@ -0,0 +1,177 @@
+/*
+Copyright 2023 GleSYS AB
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package glesysclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/glesys/external-dns-glesys/webhook/configuration"
+)
+
+// Domain is a GleSYS-hosted DNS zone, as returned by domain/listdomains.
+type Domain struct {
+	DomainName string `json:"domainname"`
+}
+
+// Record is a single resource record within a Domain, as returned by
+// domain/listrecords.
+type Record struct {
+	RecordID   int    `json:"recordid"`
+	DomainName string `json:"domainname"`
+	Host       string `json:"host"`
+	Type       string `json:"type"`
+	Data       string `json:"data"`
+	TTL        int    `json:"ttl"`
+}
+
+// Client is a minimal client for the GleSYS API domain/* calls dnsprovider
+// needs, authenticating with project/apiKey HTTP Basic Auth. httpClient is
+// expected to be configured with a Transport so calls are rate limited,
+// retried and circuit-broken.
+type Client struct {
+	httpClient *http.Client
+	baseURL    string
+	project    string
+	apiKey     string
+	userAgent  string
+}
+
+// NewClient builds a Client against cfg.GlesysAPIURL, authenticating with
+// cfg.GlesysProject/cfg.GlesysAPIKey. httpClient is typically
+// &http.Client{Transport: NewTransport(nil, cfg)}.
+func NewClient(httpClient *http.Client, cfg configuration.Config, userAgent string) *Client {
+	return &Client{
+		httpClient: httpClient,
+		baseURL:    strings.TrimRight(cfg.GlesysAPIURL, "/"),
+		project:    cfg.GlesysProject,
+		apiKey:     cfg.GlesysAPIKey,
+		userAgent:  userAgent,
+	}
+}
+
+type apiStatus struct {
+	Code int    `json:"code"`
+	Text string `json:"text"`
+}
+
+// do POSTs form to path and decodes the response's "response" envelope into
+// out, which must be a pointer to a struct with a matching field.
+func (c *Client) do(ctx context.Context, path string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.project, c.apiKey)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("glesysclient: %s returned status %d", path, resp.StatusCode)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("glesysclient: decoding %s response: %w", path, err)
+	}
+	return nil
+}
+
+// ListDomains returns every domain on the project.
+func (c *Client) ListDomains(ctx context.Context) ([]Domain, error) {
+	var out struct {
+		Response struct {
+			Status  apiStatus `json:"status"`
+			Domains []Domain  `json:"domains"`
+		} `json:"response"`
+	}
+	if err := c.do(ctx, "/domain/listdomains", url.Values{}, &out); err != nil {
+		return nil, err
+	}
+	return out.Response.Domains, nil
+}
+
+// ListRecords returns every record in domainName.
+func (c *Client) ListRecords(ctx context.Context, domainName string) ([]Record, error) {
+	var out struct {
+		Response struct {
+			Status  apiStatus `json:"status"`
+			Records []Record  `json:"records"`
+		} `json:"response"`
+	}
+	form := url.Values{"domainname": {domainName}}
+	if err := c.do(ctx, "/domain/listrecords", form, &out); err != nil {
+		return nil, err
+	}
+	return out.Response.Records, nil
+}
+
+// AddRecord creates a record in domainName.
+func (c *Client) AddRecord(ctx context.Context, domainName, host, recordType, data string, ttl int) error {
+	var out struct {
+		Response struct {
+			Status apiStatus `json:"status"`
+			Record Record    `json:"record"`
+		} `json:"response"`
+	}
+	form := url.Values{
+		"domainname": {domainName},
+		"host":       {host},
+		"type":       {recordType},
+		"data":       {data},
+		"ttl":        {strconv.Itoa(ttl)},
+	}
+	return c.do(ctx, "/domain/addrecord", form, &out)
+}
+
+// UpdateRecord updates the data/ttl of an existing record by id.
+func (c *Client) UpdateRecord(ctx context.Context, recordID int, data string, ttl int) error {
+	var out struct {
+		Response struct {
+			Status apiStatus `json:"status"`
+			Record Record    `json:"record"`
+		} `json:"response"`
+	}
+	form := url.Values{
+		"recordid": {strconv.Itoa(recordID)},
+		"data":     {data},
+		"ttl":      {strconv.Itoa(ttl)},
+	}
+	return c.do(ctx, "/domain/updaterecord", form, &out)
+}
+
+// DeleteRecord deletes a record by id.
+func (c *Client) DeleteRecord(ctx context.Context, recordID int) error {
+	var out struct {
+		Response struct {
+			Status apiStatus `json:"status"`
+		} `json:"response"`
+	}
+	form := url.Values{"recordid": {strconv.Itoa(recordID)}}
+	return c.do(ctx, "/domain/deleterecord", form, &out)
+}
@@ -0,0 +1,180 @@
+/*
+Copyright 2023 GleSYS AB
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package glesysclient wraps the HTTP transport used by the GleSYS API
+// client dnsprovider drives with a token-bucket rate limiter, retry with
+// jittered exponential backoff on 429/5xx responses, and a circuit breaker.
+// It protects GleSYS, and the external-dns controller polling this webhook,
+// from hammering each other during an upstream outage.
+package glesysclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/glesys/external-dns-glesys/webhook/configuration"
+	"github.com/glesys/external-dns-glesys/webhook/metrics"
+)
+
+// CircuitOpenError is returned by Transport.RoundTrip while the circuit
+// breaker is open. Callers can use errors.As to recover RetryAfter and
+// surface it as a Retry-After response header.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("glesysclient: circuit breaker open, retry after %s", e.RetryAfter)
+}
+
+// Transport wraps an http.RoundTripper with rate limiting, retry and a
+// circuit breaker, configured from configuration.Config.
+type Transport struct {
+	next    http.RoundTripper
+	limiter *rate.Limiter
+
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	maxElapsedTime  time.Duration
+
+	failureThreshold int
+	openDuration     time.Duration
+
+	mu               sync.Mutex
+	consecutiveFails int
+	openUntil        time.Time
+}
+
+// NewTransport wraps next (http.DefaultTransport if nil) with the rate
+// limiter, retry and circuit breaker settings from cfg.
+func NewTransport(next http.RoundTripper, cfg configuration.Config) *Transport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &Transport{
+		next:             next,
+		limiter:          rate.NewLimiter(rate.Limit(cfg.GlesysRPS), cfg.GlesysBurst),
+		initialInterval:  cfg.GlesysRetryInitialInterval,
+		maxInterval:      cfg.GlesysRetryMaxInterval,
+		maxElapsedTime:   cfg.GlesysRetryMaxElapsedTime,
+		failureThreshold: cfg.GlesysBreakerFailureThreshold,
+		openDuration:     cfg.GlesysBreakerOpenDuration,
+	}
+}
+
+func (t *Transport) breakerOpen() (bool, time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.consecutiveFails < t.failureThreshold {
+		return false, 0
+	}
+	if remaining := time.Until(t.openUntil); remaining > 0 {
+		return true, remaining
+	}
+	return false, 0
+}
+
+func (t *Transport) recordResult(ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if ok {
+		t.consecutiveFails = 0
+		return
+	}
+	t.consecutiveFails++
+	if t.consecutiveFails >= t.failureThreshold {
+		t.openUntil = time.Now().Add(t.openDuration)
+		metrics.GlesysCircuitBreakerOpen.Set(1)
+	}
+}
+
+// isRetryable reports whether resp warrants a retry with backoff: rate
+// limiting and server errors are, client errors are not.
+func isRetryable(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// RoundTrip implements http.RoundTripper. It is only safe for requests whose
+// body, if any, can be re-read via req.GetBody (as http.NewRequestWithContext
+// arranges for common body types) since a retry re-sends the request.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if open, retryAfter := t.breakerOpen(); open {
+		metrics.GlesysCircuitBreakerOpen.Set(1)
+		return nil, &CircuitOpenError{RetryAfter: retryAfter}
+	}
+	metrics.GlesysCircuitBreakerOpen.Set(0)
+
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = t.initialInterval
+	b.MaxInterval = t.maxInterval
+	b.MaxElapsedTime = t.maxElapsedTime
+
+	var resp *http.Response
+	attempt := 0
+	op := func() error {
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return backoff.Permanent(fmt.Errorf("glesysclient: cannot retry request with unrepeatable body"))
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return backoff.Permanent(err)
+			}
+			req.Body = body
+		}
+		attempt++
+
+		if err := t.limiter.Wait(req.Context()); err != nil {
+			return backoff.Permanent(err)
+		}
+
+		var err error
+		resp, err = t.next.RoundTrip(req)
+		if err != nil {
+			return err
+		}
+		if isRetryable(resp) {
+			// Drain and close so the retry doesn't leak the connection the
+			// discarded response holds open.
+			io.Copy(io.Discard, resp.Body) //nolint:errcheck
+			resp.Body.Close()
+			return fmt.Errorf("glesysclient: retryable response status %d", resp.StatusCode)
+		}
+		return nil
+	}
+
+	err := backoff.RetryNotify(op, backoff.WithContext(b, req.Context()), func(err error, wait time.Duration) {
+		metrics.GlesysAPIRetriesTotal.Inc()
+		zap.L().Sugar().Warnf("retrying GleSYS API call in %s: %v", wait, err)
+	})
+
+	t.recordResult(err == nil)
+	if err != nil {
+		// A non-nil err must never be paired with a non-nil resp: the last
+		// attempt's response (its body already drained and closed above) is
+		// not a valid response to this RoundTrip call, and net/http discards
+		// it anyway if both are set, logging a warning outside our zap setup.
+		resp = nil
+	}
+	return resp, err
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2023 GleSYS AB
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package glesysclient
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestTransport(failureThreshold int, openDuration time.Duration) *Transport {
+	return &Transport{
+		failureThreshold: failureThreshold,
+		openDuration:     openDuration,
+	}
+}
+
+func TestTransportBreakerTripsAfterThreshold(t *testing.T) {
+	tr := newTestTransport(3, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		tr.recordResult(false)
+		if open, _ := tr.breakerOpen(); open {
+			t.Fatalf("breaker open after %d failure(s), want closed (threshold 3)", i+1)
+		}
+	}
+
+	tr.recordResult(false)
+	open, retryAfter := tr.breakerOpen()
+	if !open {
+		t.Fatal("breaker closed after reaching failure threshold, want open")
+	}
+	if retryAfter <= 0 || retryAfter > time.Minute {
+		t.Errorf("retryAfter = %s, want in (0, 1m]", retryAfter)
+	}
+}
+
+func TestTransportBreakerResetsOnSuccess(t *testing.T) {
+	tr := newTestTransport(2, time.Minute)
+
+	tr.recordResult(false)
+	tr.recordResult(true)
+	tr.recordResult(false)
+
+	if open, _ := tr.breakerOpen(); open {
+		t.Fatal("breaker open after a success reset the failure streak, want closed")
+	}
+}
+
+func TestTransportBreakerClosesAfterOpenDurationElapses(t *testing.T) {
+	tr := newTestTransport(1, time.Millisecond)
+
+	tr.recordResult(false)
+	if open, _ := tr.breakerOpen(); !open {
+		t.Fatal("breaker closed immediately after tripping, want open")
+	}
+
+	time.Sleep(5 * time.Millisecond)
+
+	if open, _ := tr.breakerOpen(); open {
+		t.Fatal("breaker still open after openDuration elapsed, want closed")
+	}
+}
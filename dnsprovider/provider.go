@@ -0,0 +1,250 @@
+/*
+Copyright 2023 GleSYS AB
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package dnsprovider implements sigs.k8s.io/external-dns's provider.Provider
+// against the GleSYS DNS API, via the glesysclient sub-package.
+package dnsprovider
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+
+	"github.com/glesys/external-dns-glesys/dnsprovider/glesysclient"
+	"github.com/glesys/external-dns-glesys/webhook/configuration"
+)
+
+const defaultTTL = 3600
+
+// GlesysProvider implements provider.Provider against the GleSYS DNS API.
+type GlesysProvider struct {
+	client *glesysclient.Client
+	dryRun bool
+}
+
+// NewGlesysProvider builds a GlesysProvider, wiring its HTTP client with the
+// rate limiting, retry and circuit breaker behaviour configured in cfg. dryRun
+// additionally guards ApplyChanges for callers that don't already route
+// through the webhook layer's own dry-run handling.
+func NewGlesysProvider(cfg configuration.Config, dryRun bool, version string) (provider.Provider, error) {
+	if cfg.GlesysProject == "" || cfg.GlesysAPIKey == "" {
+		return nil, fmt.Errorf("dnsprovider: GLESYS_PROJECT and GLESYS_APIKEY must be set")
+	}
+	httpClient := &http.Client{Transport: glesysclient.NewTransport(nil, cfg)}
+	client := glesysclient.NewClient(httpClient, cfg, "external-dns-glesys/"+version)
+	return &GlesysProvider{client: client, dryRun: dryRun}, nil
+}
+
+// Records returns every DNS record across every domain on the project,
+// grouped into endpoints by domain/host/type.
+func (p *GlesysProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	domains, err := p.client.ListDomains(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("dnsprovider: listing domains: %w", err)
+	}
+
+	byNameAndType := map[string]*endpoint.Endpoint{}
+	for _, domain := range domains {
+		records, err := p.client.ListRecords(ctx, domain.DomainName)
+		if err != nil {
+			return nil, fmt.Errorf("dnsprovider: listing records for %s: %w", domain.DomainName, err)
+		}
+		for _, record := range records {
+			dnsName := fqdn(domain.DomainName, record.Host)
+			key := dnsName + "/" + record.Type
+			ep, ok := byNameAndType[key]
+			if !ok {
+				ep = endpoint.NewEndpointWithTTL(dnsName, record.Type, endpoint.TTL(record.TTL))
+				byNameAndType[key] = ep
+			}
+			ep.Targets = append(ep.Targets, record.Data)
+		}
+	}
+
+	endpoints := make([]*endpoint.Endpoint, 0, len(byNameAndType))
+	for _, ep := range byNameAndType {
+		endpoints = append(endpoints, ep)
+	}
+	return endpoints, nil
+}
+
+// ApplyChanges applies changes to the GleSYS API, resolving each endpoint's
+// owning domain from its DNSName.
+func (p *GlesysProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	if p.dryRun {
+		zap.L().Sugar().Infof("dnsprovider: dry-run, not applying %d create(s), %d update(s), %d delete(s)",
+			len(changes.Create), len(changes.UpdateNew), len(changes.Delete))
+		return nil
+	}
+
+	domains, err := p.client.ListDomains(ctx)
+	if err != nil {
+		return fmt.Errorf("dnsprovider: listing domains: %w", err)
+	}
+
+	for _, ep := range changes.Create {
+		domain, host, err := splitDomain(domains, ep.DNSName)
+		if err != nil {
+			return err
+		}
+		ttl := defaultTTL
+		if ep.RecordTTL.IsConfigured() {
+			ttl = int(ep.RecordTTL)
+		}
+		for _, target := range ep.Targets {
+			if err := p.client.AddRecord(ctx, domain, host, ep.RecordType, target, ttl); err != nil {
+				return fmt.Errorf("dnsprovider: creating %s %s record: %w", ep.DNSName, ep.RecordType, err)
+			}
+		}
+	}
+
+	for _, ep := range changes.Delete {
+		domain, host, err := splitDomain(domains, ep.DNSName)
+		if err != nil {
+			return err
+		}
+		records, err := p.client.ListRecords(ctx, domain)
+		if err != nil {
+			return fmt.Errorf("dnsprovider: listing records for %s: %w", domain, err)
+		}
+		for _, record := range matchingRecords(records, host, ep.RecordType) {
+			if err := p.client.DeleteRecord(ctx, record.RecordID); err != nil {
+				return fmt.Errorf("dnsprovider: deleting %s %s record: %w", ep.DNSName, ep.RecordType, err)
+			}
+		}
+	}
+
+	// Reconcile against the live GleSYS record set by target value, not by
+	// position: a newEp.Target that already matches an existing record's
+	// Data is left untouched, so an update that only adds or removes targets
+	// doesn't rewrite records whose value didn't change underneath it. Only
+	// records whose value is actually being replaced ("stale") are repointed
+	// to one of the new ("pending") values; any surplus on either side falls
+	// back to a plain create or delete.
+	for _, newEp := range changes.UpdateNew {
+		domain, host, err := splitDomain(domains, newEp.DNSName)
+		if err != nil {
+			return err
+		}
+		records, err := p.client.ListRecords(ctx, domain)
+		if err != nil {
+			return fmt.Errorf("dnsprovider: listing records for %s: %w", domain, err)
+		}
+		ttl := defaultTTL
+		if newEp.RecordTTL.IsConfigured() {
+			ttl = int(newEp.RecordTTL)
+		}
+
+		newTargets := map[string]bool{}
+		for _, target := range newEp.Targets {
+			newTargets[target] = true
+		}
+
+		var stale []glesysclient.Record
+		kept := map[string]bool{}
+		for _, record := range matchingRecords(records, host, newEp.RecordType) {
+			if newTargets[record.Data] && !kept[record.Data] {
+				kept[record.Data] = true
+				continue
+			}
+			stale = append(stale, record)
+		}
+
+		var pending []string
+		for _, target := range newEp.Targets {
+			if kept[target] {
+				continue
+			}
+			pending = append(pending, target)
+		}
+
+		for idx, target := range pending {
+			if idx < len(stale) {
+				if err := p.client.UpdateRecord(ctx, stale[idx].RecordID, target, ttl); err != nil {
+					return fmt.Errorf("dnsprovider: updating %s %s record: %w", newEp.DNSName, newEp.RecordType, err)
+				}
+				continue
+			}
+			if err := p.client.AddRecord(ctx, domain, host, newEp.RecordType, target, ttl); err != nil {
+				return fmt.Errorf("dnsprovider: creating %s %s record: %w", newEp.DNSName, newEp.RecordType, err)
+			}
+		}
+		for idx := len(pending); idx < len(stale); idx++ {
+			if err := p.client.DeleteRecord(ctx, stale[idx].RecordID); err != nil {
+				return fmt.Errorf("dnsprovider: deleting %s %s record: %w", newEp.DNSName, newEp.RecordType, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// AdjustEndpoints fills in the provider's default TTL for endpoints that
+// don't specify one; GleSYS has no other provider-specific constraints.
+func (p *GlesysProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	for _, ep := range endpoints {
+		if !ep.RecordTTL.IsConfigured() {
+			ep.RecordTTL = endpoint.TTL(defaultTTL)
+		}
+	}
+	return endpoints, nil
+}
+
+// GetDomainFilter returns an unrestricted filter: external-dns's own
+// --domain-filter flag, not this provider, is expected to scope domains.
+func (p *GlesysProvider) GetDomainFilter() endpoint.DomainFilter {
+	return endpoint.NewDomainFilter(nil)
+}
+
+// fqdn joins a GleSYS record host (e.g. "www", or "@" for the zone apex)
+// with its domain into a fully-qualified DNS name.
+func fqdn(domainName, host string) string {
+	if host == "" || host == "@" {
+		return domainName
+	}
+	return host + "." + domainName
+}
+
+// splitDomain finds the domain owning dnsName among domains and returns it
+// along with the GleSYS record host within that domain.
+func splitDomain(domains []glesysclient.Domain, dnsName string) (domain, host string, err error) {
+	for _, d := range domains {
+		if dnsName == d.DomainName {
+			return d.DomainName, "@", nil
+		}
+		if strings.HasSuffix(dnsName, "."+d.DomainName) {
+			return d.DomainName, strings.TrimSuffix(dnsName, "."+d.DomainName), nil
+		}
+	}
+	return "", "", fmt.Errorf("dnsprovider: no GleSYS domain on this project owns %s", dnsName)
+}
+
+// matchingRecords returns the records in records whose host and type match.
+func matchingRecords(records []glesysclient.Record, host, recordType string) []glesysclient.Record {
+	var matches []glesysclient.Record
+	for _, record := range records {
+		if record.Host == host && record.Type == recordType {
+			matches = append(matches, record)
+		}
+	}
+	return matches
+}
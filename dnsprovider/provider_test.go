@@ -0,0 +1,168 @@
+/*
+Copyright 2023 GleSYS AB
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package dnsprovider
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+
+	"github.com/glesys/external-dns-glesys/dnsprovider/glesysclient"
+	"github.com/glesys/external-dns-glesys/webhook/configuration"
+)
+
+// fakeGlesysAPI records every domain/* call it receives and serves canned
+// responses, standing in for the real GleSYS API.
+type fakeGlesysAPI struct {
+	records []glesysclient.Record
+	calls   []string
+}
+
+func (f *fakeGlesysAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_ = r.ParseForm()
+	w.Header().Set("Content-Type", "application/json")
+	switch r.URL.Path {
+	case "/domain/listdomains":
+		f.calls = append(f.calls, "listdomains")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"response": map[string]interface{}{
+				"status":  map[string]interface{}{"code": 200},
+				"domains": []glesysclient.Domain{{DomainName: "example.com"}},
+			},
+		})
+	case "/domain/listrecords":
+		f.calls = append(f.calls, "listrecords")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"response": map[string]interface{}{
+				"status":  map[string]interface{}{"code": 200},
+				"records": f.records,
+			},
+		})
+	case "/domain/updaterecord":
+		f.calls = append(f.calls, "updaterecord:"+r.FormValue("recordid")+":"+r.FormValue("data"))
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"response": map[string]interface{}{"status": map[string]interface{}{"code": 200}, "record": glesysclient.Record{}},
+		})
+	case "/domain/addrecord":
+		f.calls = append(f.calls, "addrecord:"+r.FormValue("host")+":"+r.FormValue("data"))
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"response": map[string]interface{}{"status": map[string]interface{}{"code": 200}, "record": glesysclient.Record{}},
+		})
+	case "/domain/deleterecord":
+		f.calls = append(f.calls, "deleterecord:"+r.FormValue("recordid"))
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"response": map[string]interface{}{"status": map[string]interface{}{"code": 200}},
+		})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestProvider(t *testing.T, api *fakeGlesysAPI) *GlesysProvider {
+	t.Helper()
+	server := httptest.NewServer(api)
+	t.Cleanup(server.Close)
+	cfg := configuration.Config{GlesysAPIURL: server.URL, GlesysProject: "project", GlesysAPIKey: "key"}
+	client := glesysclient.NewClient(&http.Client{}, cfg, "test-agent")
+	return &GlesysProvider{client: client}
+}
+
+func TestApplyChangesUpdateMatchesByValueNotPosition(t *testing.T) {
+	api := &fakeGlesysAPI{
+		records: []glesysclient.Record{
+			{RecordID: 1, DomainName: "example.com", Host: "www", Type: "A", Data: "1.1.1.1", TTL: 3600},
+			{RecordID: 2, DomainName: "example.com", Host: "www", Type: "A", Data: "2.2.2.2", TTL: 3600},
+		},
+	}
+	p := newTestProvider(t, api)
+
+	changes := &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", "A", "1.1.1.1", "2.2.2.2"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			// 1.1.1.1 is unchanged; 2.2.2.2 is replaced by 3.3.3.3. A purely
+			// positional pairing of ep.Targets[i] with matching[i] would risk
+			// overwriting record 1 (1.1.1.1) depending on listrecords order.
+			endpoint.NewEndpoint("www.example.com", "A", "1.1.1.1", "3.3.3.3"),
+		},
+	}
+
+	if err := p.ApplyChanges(context.Background(), changes); err != nil {
+		t.Fatalf("ApplyChanges() returned error: %v", err)
+	}
+
+	wantCall := "updaterecord:2:3.3.3.3"
+	found := false
+	for _, call := range api.calls {
+		if call == wantCall {
+			found = true
+		}
+		if call == "updaterecord:1:3.3.3.3" {
+			t.Fatalf("record 1 (unchanged target 1.1.1.1) was overwritten, calls: %v", api.calls)
+		}
+	}
+	if !found {
+		t.Fatalf("expected call %q among %v", wantCall, api.calls)
+	}
+}
+
+func TestApplyChangesUpdateReusesStaleAndDeletesLeftover(t *testing.T) {
+	api := &fakeGlesysAPI{
+		records: []glesysclient.Record{
+			{RecordID: 1, DomainName: "example.com", Host: "www", Type: "A", Data: "1.1.1.1", TTL: 3600},
+			{RecordID: 2, DomainName: "example.com", Host: "www", Type: "A", Data: "2.2.2.2", TTL: 3600},
+		},
+	}
+	p := newTestProvider(t, api)
+
+	changes := &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("www.example.com", "A", "1.1.1.1", "2.2.2.2"),
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			// Down to a single target: one stale record should be reused,
+			// the other deleted, and nothing created.
+			endpoint.NewEndpoint("www.example.com", "A", "9.9.9.9"),
+		},
+	}
+
+	if err := p.ApplyChanges(context.Background(), changes); err != nil {
+		t.Fatalf("ApplyChanges() returned error: %v", err)
+	}
+
+	var updates, deletes, creates int
+	for _, call := range api.calls {
+		switch {
+		case strings.HasPrefix(call, "updaterecord:"):
+			updates++
+		case strings.HasPrefix(call, "deleterecord:"):
+			deletes++
+		case strings.HasPrefix(call, "addrecord:"):
+			creates++
+		}
+	}
+	if updates != 1 || deletes != 1 || creates != 0 {
+		t.Fatalf("got %d update(s), %d delete(s), %d create(s), calls: %v", updates, deletes, creates, api.calls)
+	}
+}
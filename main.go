@@ -17,13 +17,17 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
+	"net"
+	"os/signal"
+	"strconv"
+	"syscall"
+
 	"github.com/glesys/external-dns-glesys/dnsprovider"
-	"github.com/glesys/external-dns-glesys/webhook"
+	"github.com/glesys/external-dns-glesys/webhook/api"
 	"github.com/glesys/external-dns-glesys/webhook/configuration"
 	"github.com/glesys/external-dns-glesys/webhook/logging"
-	"github.com/glesys/external-dns-glesys/webhook/server"
-	log "github.com/sirupsen/logrus"
 )
 
 const banner = `
@@ -45,13 +49,36 @@ var (
 
 func main() {
 	fmt.Printf(banner, Version)
-	logging.Init()
 	config := configuration.Init()
+	logging.Init(config)
+	defer logging.L.Sync() //nolint:errcheck
 
-	provider, err := dnsprovider.NewGlesysProvider(false, Version)
+	provider, err := dnsprovider.NewGlesysProvider(config, config.DryRun, Version)
 	if err != nil {
-		log.Fatalf("Failed to initialize DNS provider: %v", err)
+		logging.L.Sugar().Fatalf("Failed to initialize DNS provider: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	exposedAddr := net.JoinHostPort(config.ExposedHost, strconv.Itoa(config.ExposedPort))
+	go func() {
+		if err := api.StartExposedApi(ctx, exposedAddr); err != nil {
+			logging.L.Sugar().Errorf("exposed api stopped unexpectedly: %v", err)
+		}
+	}()
+
+	addr := net.JoinHostPort(config.ServerHost, strconv.Itoa(config.ServerPort))
+	tlsConfig := api.TLSConfig{
+		CertPath: config.TLSCertPath,
+		KeyPath:  config.TLSKeyPath,
+		CAPath:   config.TLSClientCAPath,
+	}
+	apiOpts := []api.Option{
+		api.WithDryRun(config.DryRun),
+		api.WithDryRunWebhookURL(config.DryRunWebhookURL),
+	}
+	if err := api.StartHTTPApi(ctx, provider, nil, config.ServerReadTimeout, config.ServerWriteTimeout, addr, tlsConfig, apiOpts...); err != nil {
+		logging.L.Sugar().Fatalf("webhook api stopped unexpectedly: %v", err)
 	}
-	srv := server.Init(config, webhook.New(provider))
-	server.ShutdownGracefully(srv)
 }
@@ -0,0 +1,455 @@
+/*
+Copyright 2023 GleSYS AB
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package api holds the HTTP transport layer for the external-dns webhook
+// protocol: media-type negotiation, the Records/ApplyChanges/AdjustEndpoints/
+// Negotiate handlers and the servers that expose them. It depends only on
+// provider.Provider, so it can be embedded by anything that implements that
+// interface, not just dnsprovider.
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/glesys/external-dns-glesys/dnsprovider/glesysclient"
+	"github.com/glesys/external-dns-glesys/webhook/metrics"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+const (
+	contentTypeHeader    = "Content-Type"
+	contentTypePlaintext = "text/plain"
+	acceptHeader         = "Accept"
+	varyHeader           = "Vary"
+	retryAfterHeader     = "Retry-After"
+	healthPath           = "/healthz"
+	metricsPath          = "/metrics"
+	recordsPath          = "/records"
+	adjustEndpointsPath  = "/adjustendpoints"
+	rootPath             = "/"
+	requestIDHeader      = "X-Request-Id"
+	dryRunHeader         = "X-ExternalDNS-DryRun"
+
+	dryRunWebhookTimeout = 5 * time.Second
+)
+
+type requestIDKey struct{}
+
+// Webhook implements the external-dns webhook HTTP API on top of a
+// provider.Provider.
+type Webhook struct {
+	provider         provider.Provider
+	dryRun           bool
+	dryRunWebhookURL string
+}
+
+// Option configures optional Webhook behaviour.
+type Option func(*Webhook)
+
+// WithDryRun makes ApplyChanges compute and log the changes it would make
+// without calling through to the provider. When dryRun is false, a request
+// can still opt into dry-run with the X-ExternalDNS-DryRun: true header; the
+// header cannot turn dry-run off.
+func WithDryRun(dryRun bool) Option {
+	return func(w *Webhook) { w.dryRun = dryRun }
+}
+
+// WithDryRunWebhookURL additionally POSTs the computed plan as JSON to url
+// whenever a request runs in dry-run mode. Ignored if url is empty.
+func WithDryRunWebhookURL(url string) Option {
+	return func(w *Webhook) { w.dryRunWebhookURL = url }
+}
+
+// New creates a new instance of the Webhook.
+func New(provider provider.Provider, opts ...Option) *Webhook {
+	w := &Webhook{provider: provider}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
+}
+
+// requestIDMiddleware assigns each request a request ID (reusing one
+// supplied via the X-Request-Id header, if present) and stores it on the
+// request context so handlers can key their log lines by it.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if id == "" {
+			id = uuid.NewString()
+		}
+		w.Header().Set(requestIDHeader, id)
+		ctx := context.WithValue(r.Context(), requestIDKey{}, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Mux builds the http.Handler exposing the webhook protocol endpoints.
+func (p *Webhook) Mux() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(rootPath, metrics.Middleware("negotiate", http.HandlerFunc(p.Negotiate)))
+	mux.Handle(recordsPath, metrics.Middleware("records", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			p.Records(w, r)
+		case http.MethodPost:
+			p.ApplyChanges(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})))
+	mux.Handle(adjustEndpointsPath, metrics.Middleware("adjustendpoints", http.HandlerFunc(p.AdjustEndpoints)))
+	return requestIDMiddleware(mux)
+}
+
+// ExposedMux builds the http.Handler exposing the endpoints that are safe to
+// reach from a wider network than the webhook protocol itself: the health
+// check and the Prometheus metrics scrape endpoint. It is meant to be served
+// on its own listener, separate from the main webhook endpoints.
+func ExposedMux() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(healthPath, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.Handle(metricsPath, metrics.Handler())
+	return mux
+}
+
+// TLSConfig describes the optional TLS/mTLS settings for StartHTTPApi.
+type TLSConfig struct {
+	CertPath string
+	KeyPath  string
+	CAPath   string
+}
+
+// Enabled reports whether cert/key material was configured.
+func (c TLSConfig) Enabled() bool {
+	return c.CertPath != "" && c.KeyPath != ""
+}
+
+func (c TLSConfig) tlsConfig() (*tls.Config, error) {
+	if !c.Enabled() {
+		return nil, nil
+	}
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if c.CAPath == "" {
+		return cfg, nil
+	}
+	caCert, err := os.ReadFile(c.CAPath)
+	if err != nil {
+		return nil, fmt.Errorf("error reading client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caCert) {
+		return nil, fmt.Errorf("error parsing client CA file: %s", c.CAPath)
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
+
+// StartHTTPApi wires up a provider.Provider behind the webhook HTTP API and
+// serves it on addr. readyCh, if non-nil, is closed once the listener is up
+// so callers can synchronize startup with e.g. readiness probes. StartHTTPApi
+// blocks until ctx is cancelled, then shuts the server down gracefully and
+// returns. Any listen/serve error is returned as well, ignoring
+// http.ErrServerClosed.
+func StartHTTPApi(ctx context.Context, provider provider.Provider, readyCh chan<- struct{}, readTimeout, writeTimeout time.Duration, addr string, tlsConfig TLSConfig, opts ...Option) error {
+	srv := &http.Server{
+		Addr:         addr,
+		Handler:      New(provider, opts...).Mux(),
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
+	}
+
+	tc, err := tlsConfig.tlsConfig()
+	if err != nil {
+		return err
+	}
+	srv.TLSConfig = tc
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("error starting webhook api listener: %w", err)
+	}
+	if readyCh != nil {
+		close(readyCh)
+	}
+
+	serveErrCh := make(chan error, 1)
+	go func() {
+		if tlsConfig.Enabled() {
+			zap.L().Sugar().Infof("starting webhook api with TLS, address: %s", addr)
+			serveErrCh <- srv.ServeTLS(ln, tlsConfig.CertPath, tlsConfig.KeyPath)
+		} else {
+			zap.L().Sugar().Infof("starting webhook api, address: %s", addr)
+			serveErrCh <- srv.Serve(ln)
+		}
+	}()
+
+	select {
+	case err := <-serveErrCh:
+		if err != nil && err != http.ErrServerClosed {
+			return err
+		}
+		return nil
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(shutdownCtx)
+	}
+}
+
+// StartExposedApi serves ExposedMux on addr. It is intended to be run on an
+// address that is reachable more broadly than the main webhook api, e.g.
+// 0.0.0.0 while the webhook api itself binds to 127.0.0.1.
+func StartExposedApi(ctx context.Context, addr string) error {
+	srv := &http.Server{Addr: addr, Handler: ExposedMux()}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+	zap.L().Sugar().Infof("starting exposed api, address: %s", addr)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+func (p *Webhook) contentTypeHeaderCheck(w http.ResponseWriter, r *http.Request) (codec, error) {
+	return p.headerCheck(true, w, r)
+}
+
+func (p *Webhook) acceptHeaderCheck(w http.ResponseWriter, r *http.Request) (codec, error) {
+	return p.headerCheck(false, w, r)
+}
+
+// headerCheck negotiates the protocol version from the Content-Type or
+// Accept header (isContentType selects which) and returns the codec to use
+// for this request. It always sets Vary: Accept, since the response varies
+// on that header regardless of which one was checked.
+func (p *Webhook) headerCheck(isContentType bool, w http.ResponseWriter, r *http.Request) (codec, error) {
+	w.Header().Set(varyHeader, acceptHeader)
+	var header string
+	if isContentType {
+		header = r.Header.Get(contentTypeHeader)
+	} else {
+		header = r.Header.Get(acceptHeader)
+	}
+	if len(header) == 0 {
+		w.Header().Set(contentTypeHeader, contentTypePlaintext)
+		w.WriteHeader(http.StatusNotAcceptable)
+		msg := "client must provide "
+		if isContentType {
+			msg += "a content type"
+		} else {
+			msg += "an accept header"
+		}
+		err := fmt.Errorf(msg)
+		_, writeErr := fmt.Fprint(w, err.Error())
+		if writeErr != nil {
+			requestLog(r).Fatal("error writing error message to response writer", zap.Error(writeErr))
+		}
+		return codec{}, err
+	}
+	c, err := negotiateMediaType(header)
+	if err != nil {
+		w.Header().Set(contentTypeHeader, contentTypePlaintext)
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		msg := "client must provide a valid versioned media type in the "
+		if isContentType {
+			msg += "content type"
+		} else {
+			msg += "accept header"
+		}
+		err := fmt.Errorf(msg+": %s", err.Error())
+		_, writeErr := fmt.Fprint(w, err.Error())
+		if writeErr != nil {
+			requestLog(r).Fatal("error writing error message to response writer", zap.Error(writeErr))
+		}
+		return codec{}, err
+	}
+	return c, nil
+}
+
+// Records handles the get request for records
+func (p *Webhook) Records(w http.ResponseWriter, r *http.Request) {
+	c, err := p.acceptHeaderCheck(w, r)
+	if err != nil {
+		requestLog(r).Error("accept header check failed", zap.Error(err))
+		return
+	}
+	requestLog(r).Debug("requesting records")
+	ctx := r.Context()
+	start := time.Now()
+	records, err := p.provider.Records(ctx)
+	metrics.GlesysAPICallDuration.WithLabelValues("records").Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.GlesysAPIErrorsTotal.WithLabelValues("records").Inc()
+		requestLog(r).Error("error getting records", zap.Error(err))
+		writeProviderError(w, err)
+		return
+	}
+	metrics.RecordsReturned.Set(float64(len(records)))
+	requestLog(r).Debug("returning records", zap.Int("count", len(records)))
+	w.Header().Set(contentTypeHeader, string(c.contentType))
+	if err := c.encodeRecords(w, records); err != nil {
+		requestLog(r).Error("error encoding records", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+// ApplyChanges handles the post request for record changes
+func (p *Webhook) ApplyChanges(w http.ResponseWriter, r *http.Request) {
+	c, err := p.contentTypeHeaderCheck(w, r)
+	if err != nil {
+		requestLog(r).Error("content type header check failed", zap.Error(err))
+		return
+	}
+	ctx := r.Context()
+	changes, err := c.decodeChanges(r.Body)
+	if err != nil {
+		w.Header().Set(contentTypeHeader, contentTypePlaintext)
+		w.WriteHeader(http.StatusBadRequest)
+		errMsg := fmt.Sprintf("error decoding changes: %s", err.Error())
+		if _, writeError := fmt.Fprint(w, errMsg); writeError != nil {
+			requestLog(r).Fatal("error writing error message to response writer", zap.Error(writeError))
+		}
+		requestLog(r).Info(errMsg, zap.Error(err))
+		return
+	}
+	requestLog(r).Debug("requesting apply changes",
+		zap.Int("create", len(changes.Create)), zap.Int("updateOld", len(changes.UpdateOld)),
+		zap.Int("updateNew", len(changes.UpdateNew)), zap.Int("delete", len(changes.Delete)))
+
+	if p.dryRun || r.Header.Get(dryRunHeader) == "true" {
+		accept, err := p.acceptHeaderCheck(w, r)
+		if err != nil {
+			requestLog(r).Error("accept header check failed", zap.Error(err))
+			return
+		}
+		p.applyChangesDryRun(w, r, accept, changes)
+		return
+	}
+
+	start := time.Now()
+	err = p.provider.ApplyChanges(ctx, changes)
+	metrics.GlesysAPICallDuration.WithLabelValues("applychanges").Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.GlesysAPIErrorsTotal.WithLabelValues("applychanges").Inc()
+		writeProviderError(w, err)
+		return
+	}
+	metrics.ApplyChangesTotal.WithLabelValues("create").Add(float64(len(changes.Create)))
+	metrics.ApplyChangesTotal.WithLabelValues("update").Add(float64(len(changes.UpdateNew)))
+	metrics.ApplyChangesTotal.WithLabelValues("delete").Add(float64(len(changes.Delete)))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdjustEndpoints handles the post request for adjusting endpoints
+func (p *Webhook) AdjustEndpoints(w http.ResponseWriter, r *http.Request) {
+	c, err := p.contentTypeHeaderCheck(w, r)
+	if err != nil {
+		requestLog(r).Error("content type header check failed", zap.Error(err))
+		return
+	}
+	accept, err := p.acceptHeaderCheck(w, r)
+	if err != nil {
+		requestLog(r).Error("accept header check failed", zap.Error(err))
+		return
+	}
+
+	pve, err := c.decodeEndpoints(r.Body)
+	if err != nil {
+		w.Header().Set(contentTypeHeader, contentTypePlaintext)
+		w.WriteHeader(http.StatusBadRequest)
+		errMessage := fmt.Sprintf("failed to decode request body: %v", err)
+		requestLog(r).Info(errMessage)
+		if _, writeError := fmt.Fprint(w, errMessage); writeError != nil {
+			requestLog(r).Fatal("error writing error message to response writer", zap.Error(writeError))
+		}
+		return
+	}
+	requestLog(r).Debug("requesting adjust endpoints", zap.Int("count", len(pve)))
+	pve, err = p.provider.AdjustEndpoints(pve)
+	if err != nil {
+		requestLog(r).Error("failed to call adjust endpoints", zap.Error(err))
+		w.Header().Set(contentTypeHeader, contentTypePlaintext)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	requestLog(r).Debug("return adjust endpoints response", zap.Int("resultEndpointCount", len(pve)))
+	w.Header().Set(contentTypeHeader, string(accept.contentType))
+	if err := accept.encodeEndpoints(w, pve); err != nil {
+		requestLog(r).Fatal("error writing response", zap.Error(err))
+	}
+}
+
+// Negotiate returns the provider's domain filter, used by external-dns to
+// negotiate which domains this webhook is responsible for.
+func (p *Webhook) Negotiate(w http.ResponseWriter, r *http.Request) {
+	c, err := p.acceptHeaderCheck(w, r)
+	if err != nil {
+		requestLog(r).Error("accept header check failed", zap.Error(err))
+		return
+	}
+	w.Header().Set(contentTypeHeader, string(c.contentType))
+	if err := c.encodeFilter(w, p.provider.GetDomainFilter()); err != nil {
+		requestLog(r).Error("failed to marshal domain filter", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+}
+
+// writeProviderError maps an error from provider.Provider to a response: a
+// glesysclient.CircuitOpenError becomes 503 with a Retry-After header, so a
+// well-behaved external-dns controller backs off instead of hammering a
+// GleSYS outage; anything else becomes a plain 500.
+func writeProviderError(w http.ResponseWriter, err error) {
+	var circuitOpen *glesysclient.CircuitOpenError
+	if errors.As(err, &circuitOpen) {
+		w.Header().Set(contentTypeHeader, contentTypePlaintext)
+		w.Header().Set(retryAfterHeader, strconv.Itoa(int(circuitOpen.RetryAfter.Seconds())))
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set(contentTypeHeader, contentTypePlaintext)
+	w.WriteHeader(http.StatusInternalServerError)
+}
+
+// requestLog returns a logger keyed by the request's method, path and
+// request ID (see requestIDMiddleware).
+func requestLog(r *http.Request) *zap.Logger {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return zap.L().With(
+		zap.String("requestMethod", r.Method),
+		zap.String("requestPath", r.URL.Path),
+		zap.String("requestId", id),
+	)
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2023 GleSYS AB
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+var dryRunWebhookClient = &http.Client{Timeout: dryRunWebhookTimeout}
+
+// applyChangesDryRun computes and logs what ApplyChanges would have done
+// for changes without calling the provider, optionally forwards the plan to
+// a review webhook, and responds 200 OK with the plan as JSON instead of
+// the usual 204 No Content.
+func (p *Webhook) applyChangesDryRun(w http.ResponseWriter, r *http.Request, c codec, changes *plan.Changes) {
+	requestLog(r).Info("dry-run: not applying changes",
+		zap.Int("create", len(changes.Create)), zap.Int("updateOld", len(changes.UpdateOld)),
+		zap.Int("updateNew", len(changes.UpdateNew)), zap.Int("delete", len(changes.Delete)))
+
+	body, err := json.Marshal(changes)
+	if err != nil {
+		requestLog(r).Error("dry-run: error encoding plan", zap.Error(err))
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if p.dryRunWebhookURL != "" {
+		go p.postDryRunPlan(body)
+	}
+
+	w.Header().Set(contentTypeHeader, string(c.contentType))
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		requestLog(r).Error("dry-run: error writing plan response", zap.Error(err))
+	}
+}
+
+// postDryRunPlan forwards a dry-run plan to the configured review webhook.
+// Errors are logged rather than surfaced, since this runs in its own
+// goroutine, independently of the webhook response.
+func (p *Webhook) postDryRunPlan(body []byte) {
+	resp, err := dryRunWebhookClient.Post(p.dryRunWebhookURL, string(mediaTypeVersion("1")), bytes.NewReader(body))
+	if err != nil {
+		zap.L().Error("dry-run: error posting plan to review webhook", zap.Error(err), zap.String("url", p.dryRunWebhookURL))
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		zap.L().Error("dry-run: review webhook returned an error status",
+			zap.Int("status", resp.StatusCode), zap.String("url", p.dryRunWebhookURL))
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright 2023 GleSYS AB
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+const mediaTypeFormat = "application/external.dns.webhook+json;"
+
+func mediaTypeVersion(v string) mediaType {
+	return mediaType(mediaTypeFormat + "version=" + v)
+}
+
+type mediaType string
+
+// codec knows how to encode/decode the webhook wire payloads for one
+// protocol version. Registering a new version here is the only thing a
+// future protocol revision needs to do; handlers dispatch through the
+// negotiated codec and never hard-code a version.
+type codec struct {
+	version         string
+	contentType     mediaType
+	encodeRecords   func(w io.Writer, records []*endpoint.Endpoint) error
+	decodeChanges   func(r io.Reader) (*plan.Changes, error)
+	decodeEndpoints func(r io.Reader) ([]*endpoint.Endpoint, error)
+	encodeEndpoints func(w io.Writer, endpoints []*endpoint.Endpoint) error
+	encodeFilter    func(w io.Writer, filter endpoint.DomainFilter) error
+}
+
+// mediaTypeRegistry holds the codec for every supported protocol version,
+// keyed by version string. Populated by registerCodec in this package's
+// schema_v*.go files.
+var mediaTypeRegistry = map[string]codec{}
+
+func registerCodec(c codec) {
+	mediaTypeRegistry[c.version] = c
+}
+
+// mediaTypeOffer is one entry of a (possibly multi-valued) Accept header,
+// e.g. "application/external.dns.webhook+json;version=2;q=0.9".
+type mediaTypeOffer struct {
+	version string
+	q       float64
+}
+
+func parseMediaTypeOffers(header string) []mediaTypeOffer {
+	var offers []mediaTypeOffer
+	for _, part := range strings.Split(header, ",") {
+		segs := strings.Split(part, ";")
+		base := strings.TrimSpace(segs[0]) + ";"
+		if base != mediaTypeFormat {
+			continue
+		}
+		version, q := "", 1.0
+		for _, seg := range segs[1:] {
+			seg = strings.TrimSpace(seg)
+			switch {
+			case strings.HasPrefix(seg, "version="):
+				version = strings.TrimPrefix(seg, "version=")
+			case strings.HasPrefix(seg, "q="):
+				if parsed, err := strconv.ParseFloat(strings.TrimPrefix(seg, "q="), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if version == "" {
+			continue
+		}
+		offers = append(offers, mediaTypeOffer{version: version, q: q})
+	}
+	return offers
+}
+
+// negotiateMediaType parses an Accept (or Content-Type) header value that
+// may list several "application/external.dns.webhook+json;version=N" offers,
+// each with an optional q-value, and returns the codec for the
+// highest-numbered version that is both offered with q > 0 and registered
+// in mediaTypeRegistry.
+func negotiateMediaType(header string) (codec, error) {
+	best := -1
+	for _, offer := range parseMediaTypeOffers(header) {
+		if offer.q <= 0 {
+			continue
+		}
+		if _, ok := mediaTypeRegistry[offer.version]; !ok {
+			continue
+		}
+		v, err := strconv.Atoi(offer.version)
+		if err != nil {
+			continue
+		}
+		if v > best {
+			best = v
+		}
+	}
+	if best < 0 {
+		return codec{}, fmt.Errorf("unsupported media type version(s): '%s'. Supported media types are: '%s'", header, supportedMediaTypesString())
+	}
+	return mediaTypeRegistry[strconv.Itoa(best)], nil
+}
+
+func supportedMediaTypesString() string {
+	versions := make([]string, 0, len(mediaTypeRegistry))
+	for v := range mediaTypeRegistry {
+		versions = append(versions, v)
+	}
+	sort.Strings(versions)
+	parts := make([]string, len(versions))
+	for i, v := range versions {
+		parts[i] = string(mediaTypeVersion(v))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2023 GleSYS AB
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import "testing"
+
+func TestParseMediaTypeOffers(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []mediaTypeOffer
+	}{
+		{
+			name:   "single offer defaults q to 1",
+			header: "application/external.dns.webhook+json;version=1",
+			want:   []mediaTypeOffer{{version: "1", q: 1}},
+		},
+		{
+			name:   "explicit q value",
+			header: "application/external.dns.webhook+json;version=2;q=0.9",
+			want:   []mediaTypeOffer{{version: "2", q: 0.9}},
+		},
+		{
+			name:   "multiple offers",
+			header: "application/external.dns.webhook+json;version=1;q=0.5, application/external.dns.webhook+json;version=2",
+			want:   []mediaTypeOffer{{version: "1", q: 0.5}, {version: "2", q: 1}},
+		},
+		{
+			name:   "unrelated media type is ignored",
+			header: "text/plain, application/external.dns.webhook+json;version=1",
+			want:   []mediaTypeOffer{{version: "1", q: 1}},
+		},
+		{
+			name:   "offer without a version is ignored",
+			header: "application/external.dns.webhook+json;q=0.8",
+			want:   nil,
+		},
+		{
+			name:   "unparsable q falls back to 1",
+			header: "application/external.dns.webhook+json;version=1;q=bogus",
+			want:   []mediaTypeOffer{{version: "1", q: 1}},
+		},
+		{
+			name:   "empty header",
+			header: "",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseMediaTypeOffers(tt.header)
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseMediaTypeOffers(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("parseMediaTypeOffers(%q)[%d] = %v, want %v", tt.header, i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestNegotiateMediaType(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		wantVersion string
+		wantErr     bool
+	}{
+		{
+			name:        "single supported version",
+			header:      "application/external.dns.webhook+json;version=1",
+			wantVersion: "1",
+		},
+		{
+			name:        "picks the highest mutually supported version",
+			header:      "application/external.dns.webhook+json;version=1, application/external.dns.webhook+json;version=2",
+			wantVersion: "2",
+		},
+		{
+			name:        "a zero q-value offer is not a candidate",
+			header:      "application/external.dns.webhook+json;version=2;q=0, application/external.dns.webhook+json;version=1",
+			wantVersion: "1",
+		},
+		{
+			name:    "unregistered version",
+			header:  "application/external.dns.webhook+json;version=99",
+			wantErr: true,
+		},
+		{
+			name:    "unparsable header",
+			header:  "garbage",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := negotiateMediaType(tt.header)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("negotiateMediaType(%q) = %v, want error", tt.header, c)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("negotiateMediaType(%q) returned unexpected error: %v", tt.header, err)
+			}
+			if c.version != tt.wantVersion {
+				t.Errorf("negotiateMediaType(%q) version = %q, want %q", tt.header, c.version, tt.wantVersion)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2023 GleSYS AB
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package api
+
+import (
+	"encoding/json"
+	"io"
+
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// v2 is a stub: the wire format is currently byte-for-byte identical to v1.
+// It exists so a future external-dns webhook protocol revision has a version
+// to register against without having to touch the handlers in api.go - only
+// the encode/decode functions below need to change.
+func init() {
+	registerCodec(codec{
+		version:     "2",
+		contentType: mediaTypeVersion("2"),
+		encodeRecords: func(w io.Writer, records []*endpoint.Endpoint) error {
+			return json.NewEncoder(w).Encode(records)
+		},
+		decodeChanges: func(r io.Reader) (*plan.Changes, error) {
+			var changes plan.Changes
+			if err := json.NewDecoder(r).Decode(&changes); err != nil {
+				return nil, err
+			}
+			return &changes, nil
+		},
+		decodeEndpoints: func(r io.Reader) ([]*endpoint.Endpoint, error) {
+			var endpoints []*endpoint.Endpoint
+			if err := json.NewDecoder(r).Decode(&endpoints); err != nil {
+				return nil, err
+			}
+			return endpoints, nil
+		},
+		encodeEndpoints: func(w io.Writer, endpoints []*endpoint.Endpoint) error {
+			return json.NewEncoder(w).Encode(endpoints)
+		},
+		encodeFilter: func(w io.Writer, filter endpoint.DomainFilter) error {
+			return json.NewEncoder(w).Encode(filter)
+		},
+	})
+}
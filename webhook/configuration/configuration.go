@@ -17,25 +17,73 @@ limitations under the License.
 package configuration
 
 import (
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/caarlos0/env/v8"
-	log "github.com/sirupsen/logrus"
 )
 
 // Config struct for configuration environmental variables
 type Config struct {
-	ServerHost         string        `env:"SERVER_HOST" envDefault:"localhost"`
+	ServerHost         string        `env:"SERVER_HOST" envDefault:"127.0.0.1"`
 	ServerPort         int           `env:"SERVER_PORT" envDefault:"8888"`
 	ServerReadTimeout  time.Duration `env:"SERVER_READ_TIMEOUT"`
 	ServerWriteTimeout time.Duration `env:"SERVER_WRITE_TIMEOUT"`
+
+	// ExposedHost/ExposedPort serve endpoints that are safe to expose beyond
+	// the webhook api itself, e.g. /healthz and /metrics, mirroring the
+	// upstream external-dns webhook convention of a wider-reachable listener.
+	ExposedHost string `env:"EXPOSED_HOST" envDefault:"0.0.0.0"`
+	ExposedPort int    `env:"EXPOSED_PORT" envDefault:"8080"`
+
+	// TLS/mTLS for the webhook api. Leaving TLSCertPath/TLSKeyPath empty
+	// serves plain HTTP, as before. TLSClientCAPath additionally enables
+	// client certificate verification (mTLS).
+	TLSCertPath     string `env:"TLS_CERT_PATH"`
+	TLSKeyPath      string `env:"TLS_KEY_PATH"`
+	TLSClientCAPath string `env:"TLS_CLIENT_CA_PATH"`
+
+	// LogLevel is a zapcore.Level string (debug, info, warn, error, ...).
+	// LogFormat is either "json" (default, production) or "console".
+	LogLevel  string `env:"LOG_LEVEL" envDefault:"info"`
+	LogFormat string `env:"LOG_FORMAT" envDefault:"json"`
+
+	// DryRun makes ApplyChanges compute and log the changes it would make
+	// without calling GleSYS, returning them in the response instead. A
+	// request can also opt into this per-call with the
+	// X-ExternalDNS-DryRun: true header, regardless of this setting.
+	// DryRunWebhookURL, if set, additionally receives an HTTP POST of the
+	// computed plan as JSON, for external review.
+	DryRun           bool   `env:"DRY_RUN"`
+	DryRunWebhookURL string `env:"DRY_RUN_WEBHOOK_URL"`
+
+	// GleSYS API credentials and base URL. GlesysProject/GlesysAPIKey are
+	// sent as HTTP Basic Auth credentials on every API call.
+	GlesysAPIURL  string `env:"GLESYS_API_URL" envDefault:"https://api.glesys.com"`
+	GlesysProject string `env:"GLESYS_PROJECT"`
+	GlesysAPIKey  string `env:"GLESYS_APIKEY"`
+
+	// GleSYS API client resilience: a token-bucket rate limiter, retry with
+	// jittered exponential backoff on 429/5xx, and a circuit breaker that
+	// trips after GlesysBreakerFailureThreshold consecutive failures.
+	GlesysRPS                     float64       `env:"GLESYS_RPS" envDefault:"10"`
+	GlesysBurst                   int           `env:"GLESYS_BURST" envDefault:"20"`
+	GlesysRetryInitialInterval    time.Duration `env:"GLESYS_RETRY_INITIAL_INTERVAL" envDefault:"500ms"`
+	GlesysRetryMaxInterval        time.Duration `env:"GLESYS_RETRY_MAX_INTERVAL" envDefault:"30s"`
+	GlesysRetryMaxElapsedTime     time.Duration `env:"GLESYS_RETRY_MAX_ELAPSED_TIME" envDefault:"1m"`
+	GlesysBreakerFailureThreshold int           `env:"GLESYS_BREAKER_FAILURE_THRESHOLD" envDefault:"5"`
+	GlesysBreakerOpenDuration     time.Duration `env:"GLESYS_BREAKER_OPEN_DURATION" envDefault:"30s"`
 }
 
-// Init sets up configuration by reading set environmental variables
+// Init sets up configuration by reading set environmental variables. Errors
+// are reported directly to stderr, since the logger itself is configured
+// from the values this function returns.
 func Init() Config {
 	cfg := Config{}
 	if err := env.Parse(&cfg); err != nil {
-		log.Fatalf("Error reading configuration from environment: %v", err)
+		fmt.Fprintf(os.Stderr, "Error reading configuration from environment: %v\n", err)
+		os.Exit(1)
 	}
 	return cfg
 }
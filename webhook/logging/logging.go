@@ -0,0 +1,60 @@
+/*
+Copyright 2023 GleSYS AB
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package logging sets up the process-wide structured logger. It replaces
+// the previous logrus-based setup with go.uber.org/zap, configured by the
+// LOG_LEVEL and LOG_FORMAT environment variables.
+package logging
+
+import (
+	"fmt"
+
+	"github.com/glesys/external-dns-glesys/webhook/configuration"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// L is the process-wide logger. It is safe to use concurrently and is
+// replaced once by Init at startup.
+var L = zap.NewNop()
+
+// Init configures the global logger from cfg.LogLevel and cfg.LogFormat. It
+// must be called once, before any other package logs. On an invalid
+// LOG_LEVEL it falls back to info and logs a warning.
+func Init(cfg configuration.Config) {
+	level := zapcore.InfoLevel
+	levelErr := level.UnmarshalText([]byte(cfg.LogLevel))
+
+	var zapCfg zap.Config
+	if cfg.LogFormat == "console" {
+		zapCfg = zap.NewDevelopmentConfig()
+	} else {
+		zapCfg = zap.NewProductionConfig()
+	}
+	zapCfg.Level = zap.NewAtomicLevelAt(level)
+
+	logger, err := zapCfg.Build()
+	if err != nil {
+		panic(fmt.Sprintf("failed to build logger: %v", err))
+	}
+
+	L = logger
+	zap.ReplaceGlobals(logger)
+
+	if levelErr != nil {
+		L.Sugar().Warnf("invalid LOG_LEVEL %q, defaulting to info", cfg.LogLevel)
+	}
+}
@@ -0,0 +1,134 @@
+/*
+Copyright 2023 GleSYS AB
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package metrics holds the Prometheus instrumentation for the webhook HTTP
+// API and the GleSYS API client it drives, exposed for scraping on a
+// separate listener from the webhook endpoints themselves.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "external_dns_glesys"
+
+var (
+	// RequestsTotal counts webhook HTTP requests by endpoint and status code.
+	RequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "requests_total",
+		Help:      "Total number of webhook HTTP requests, by endpoint and status code.",
+	}, []string{"endpoint", "status"})
+
+	// RequestDuration observes webhook HTTP request latency by endpoint.
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of webhook HTTP requests, by endpoint.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	// RecordsReturned tracks how many DNS records the last Records call
+	// returned.
+	RecordsReturned = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "records_returned",
+		Help:      "Number of DNS records returned by the last Records call.",
+	})
+
+	// ApplyChangesTotal counts endpoints processed by ApplyChanges, by
+	// change type (create, update, delete).
+	ApplyChangesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "apply_changes_total",
+		Help:      "Total number of endpoints processed by ApplyChanges, by change type.",
+	}, []string{"type"})
+
+	// GlesysAPICallDuration observes GleSYS API call latency by operation.
+	GlesysAPICallDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "glesys_api",
+		Name:      "call_duration_seconds",
+		Help:      "Duration of calls to the GleSYS API, by operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation"})
+
+	// GlesysAPIErrorsTotal counts errored GleSYS API calls by operation.
+	GlesysAPIErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "glesys_api",
+		Name:      "errors_total",
+		Help:      "Total number of errored GleSYS API calls, by operation.",
+	}, []string{"operation"})
+
+	// GlesysAPIRetriesTotal counts retries performed by the GleSYS API
+	// client's backoff middleware.
+	GlesysAPIRetriesTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "glesys_api",
+		Name:      "retries_total",
+		Help:      "Total number of retries performed against the GleSYS API.",
+	})
+
+	// GlesysCircuitBreakerOpen is 1 while the GleSYS API client's circuit
+	// breaker is open (short-circuiting calls) and 0 otherwise.
+	GlesysCircuitBreakerOpen = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "glesys_api",
+		Name:      "circuit_breaker_open",
+		Help:      "1 while the GleSYS API circuit breaker is open, 0 otherwise.",
+	})
+)
+
+// statusRecorder captures the status code an http.Handler wrote, defaulting
+// to 200 since a handler that never calls WriteHeader implicitly sends it.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Middleware instruments next with RequestsTotal and RequestDuration,
+// labelled by endpoint.
+func Middleware(endpoint string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		RequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+		RequestsTotal.WithLabelValues(endpoint, strconv.Itoa(rec.status)).Inc()
+	})
+}
+
+// Handler exposes the registered metrics in the Prometheus exposition
+// format, for mounting on the exposed listener alongside /healthz.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}